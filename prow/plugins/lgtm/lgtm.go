@@ -17,8 +17,10 @@ limitations under the License.
 package lgtm
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/sets"
@@ -36,19 +38,73 @@ var (
 	lgtmRe              = regexp.MustCompile(`(?mi)^/lgtm(?: no-issue)?\s*$`)
 	lgtmCancelRe        = regexp.MustCompile(`(?mi)^/lgtm cancel\s*$`)
 	removeLGTMLabelNoti = "New changes are detected. LGTM label has been removed."
+
+	// needsReviewLabel/changesRequestedLabel are the other two labels in the
+	// review-state lifecycle driven by Lgtm.ReviewStateLabels; the third
+	// state reuses the existing lgtmLabel.
+	needsReviewLabel      = "needs-review"
+	changesRequestedLabel = "changes-requested"
+
+	holdRe       = regexp.MustCompile(`(?mi)^/hold\s*$`)
+	holdCancelRe = regexp.MustCompile(`(?mi)^/hold cancel\s*$`)
+
+	// lgtmTreeCommentFmt/Re mark a bot comment that records the tree SHA of
+	// the commit an /lgtm was applied to, so a later push that reproduces the
+	// same tree (a rebase or an empty merge commit) doesn't lose the label.
+	lgtmTreeCommentFmt = "<!-- lgtm-tree:%s -->"
+	lgtmTreeCommentRe  = regexp.MustCompile(`<!-- lgtm-tree:([0-9a-f]+) -->`)
+
+	// lgtmStateCommentFmt/Re wrap the JSON-encoded LGTMState for a PR using
+	// Lgtm.RequireFileCoverage, so the per-file coverage accounting survives
+	// plugin restarts.
+	lgtmStateCommentFmt = "<!-- lgtm-file-state:%s -->"
+	lgtmStateCommentRe  = regexp.MustCompile(`(?s)<!-- lgtm-file-state:({.*}) -->`)
+
+	// lgtmReviewersCommentFmt/Re wrap the JSON-encoded list of current
+	// LGTMers for a PR using Lgtm.ReviewersRequired.
+	lgtmReviewersCommentFmt = "<!-- lgtm-reviewers:%s -->"
+	lgtmReviewersCommentRe  = regexp.MustCompile(`<!-- lgtm-reviewers:(\[.*\]) -->`)
 )
 
 func init() {
 	plugins.RegisterGenericCommentHandler(pluginName, handleGenericComment, helpProvider)
+	plugins.RegisterReviewEventHandler(pluginName, handleReviewEvent, helpProvider)
 	plugins.RegisterPullRequestHandler(pluginName, func(pc plugins.PluginClient, pe github.PullRequestEvent) error {
-		return handlePullRequest(pc.GitHubClient, pe, pc.Logger)
+		return handlePullRequest(pc.GitHubClient, pe, pc.PluginConfig, pc.Logger)
 	}, helpProvider)
 }
 
 func helpProvider(config *plugins.Configuration, enabledRepos []string) (*pluginhelp.PluginHelp, error) {
-	// The Config field is omitted because this plugin is not configurable.
+	configInfo := make(map[string]string)
+	for _, repo := range enabledRepos {
+		parts := strings.Split(repo, "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid repo in enabledRepos: %q", repo)
+		}
+		opts := config.LgtmFor(parts[0], parts[1])
+		if opts == nil {
+			continue
+		}
+		var info []string
+		if opts.StickyLGTM {
+			info = append(info, "Sticky LGTM is enabled: the lgtm label is kept across pushes that don't change the tree (e.g. a rebase).")
+		}
+		if opts.RequireFileCoverage {
+			info = append(info, "Per-file LGTM coverage is required: the lgtm label is only applied once every changed file has an LGTM from a qualified reviewer.")
+		}
+		if opts.ReviewersRequired > 1 {
+			info = append(info, fmt.Sprintf("%d distinct qualified reviewers must LGTM before the lgtm label is applied.", opts.ReviewersRequired))
+		}
+		if opts.ReviewStateLabels {
+			info = append(info, "Review-state labels are enabled: needs-review, changes-requested, and lgtm track the PR's review lifecycle instead of a bare lgtm label.")
+		}
+		if len(info) > 0 {
+			configInfo[repo] = strings.Join(info, " ")
+		}
+	}
 	pluginHelp := &pluginhelp.PluginHelp{
 		Description: "The lgtm plugin manages the application and removal of the 'lgtm' (Looks Good To Me) label which is typically used to gate merging.",
+		Config:      configInfo,
 	}
 	pluginHelp.AddCommand(pluginhelp.Command{
 		Usage:       "/lgtm [cancel]",
@@ -57,6 +113,13 @@ func helpProvider(config *plugins.Configuration, enabledRepos []string) (*plugin
 		WhoCanUse:   "Collaborators on the repository. '/lgtm cancel' can be used additionally by the PR author.",
 		Examples:    []string{"/lgtm", "/lgtm cancel"},
 	})
+	pluginHelp.AddCommand(pluginhelp.Command{
+		Usage:       "/hold [cancel]",
+		Description: "Requests changes, moving a PR to the 'changes-requested' state in repos with Lgtm.ReviewStateLabels enabled.",
+		Featured:    false,
+		WhoCanUse:   "Assignees, collaborators, and OWNERS reviewers/approvers.",
+		Examples:    []string{"/hold", "/hold cancel"},
+	})
 	return pluginHelp, nil
 }
 
@@ -65,10 +128,12 @@ type githubClient interface {
 	AddLabel(owner, repo string, number int, label string) error
 	AssignIssue(owner, repo string, number int, assignees []string) error
 	CreateComment(owner, repo string, number int, comment string) error
+	EditComment(org, repo string, ID int, comment string) error
 	RemoveLabel(owner, repo string, number int, label string) error
 	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
 	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
 	GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error)
+	GetSingleCommit(org, repo, SHA string) (github.RepositoryCommit, error)
 	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
 	DeleteComment(org, repo string, ID int) error
 	BotName() (string, error)
@@ -78,12 +143,162 @@ func handleGenericComment(pc plugins.PluginClient, e github.GenericCommentEvent)
 	return handle(pc.GitHubClient, pc.PluginConfig, pc.OwnersClient, pc.Logger, &e)
 }
 
+func handleReviewEvent(pc plugins.PluginClient, re github.ReviewEvent) error {
+	return handleReview(pc.GitHubClient, pc.PluginConfig, pc.OwnersClient, pc.Logger, &re)
+}
+
+// handleReview treats a submitted GitHub review as an alternate source of
+// LGTM, equivalent to the /lgtm and /lgtm cancel comment commands: an
+// APPROVED review applies the label, a CHANGES_REQUESTED review or the
+// DISMISSED of a prior approval removes it. In repos with
+// Lgtm.ReviewStateLabels enabled, the review instead feeds the same
+// transition table as /lgtm and /hold comments; in repos with
+// Lgtm.RequireFileCoverage or Lgtm.ReviewersRequired enabled, it feeds the
+// same per-file OWNERS coverage or reviewer-threshold accounting an /lgtm
+// comment would, rather than applying the label outright.
+func handleReview(gc githubClient, config *plugins.Configuration, ownersClient repoowners.Interface, log *logrus.Entry, re *github.ReviewEvent) error {
+	if re.PullRequest.State != "open" {
+		return nil
+	}
+
+	org := re.Repo.Owner.Login
+	repo := re.Repo.Name
+	number := re.PullRequest.Number
+	login := re.Review.User.Login
+
+	if login == re.PullRequest.User.Login {
+		// GitHub won't let an author approve their own PR, but a self-review
+		// requesting changes is possible; either way it's not a command the
+		// author typed at the bot, so there's nothing to reply to.
+		return nil
+	}
+
+	// opts decides which mode's accounting the review feeds; the checks
+	// below follow the same ReviewStateLabels -> RequireFileCoverage ->
+	// ReviewersRequired precedence handle() uses for the /lgtm comment path,
+	// so a repo with more than one of these set behaves identically whether
+	// a reviewer comments /lgtm or clicks Approve.
+	opts := config.LgtmFor(org, repo)
+
+	if opts != nil && opts.ReviewStateLabels {
+		ok, err := canLGTM(gc, ownersClient, config, org, repo, number, login)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// Unlike an /lgtm comment, a review isn't an explicit command
+			// aimed at the bot, so an unqualified reviewer is silently
+			// ignored rather than answered with a rejection comment.
+			return nil
+		}
+		event, ok := reviewEventFromReviewState(re.Review.State)
+		if !ok {
+			return nil
+		}
+		old, hadLabel, err := currentReviewState(gc, org, repo, number, log)
+		if err != nil {
+			return err
+		}
+		return applyReviewState(gc, org, repo, number, log, old, hadLabel, transition(old, event))
+	}
+
+	// Per-file coverage mode gates on OWNERS ownership of the changed files
+	// instead of collaborator/assignee status, the same way handleFileCoverage
+	// bypasses the canLGTM gate for the /lgtm comment path (handle()'s comment
+	// above that call: "Per-file coverage mode replaces the
+	// assignee/collaborator gating"). Applying canLGTM here too would reject
+	// an OWNERS reviewer who isn't also a repo collaborator or PR assignee,
+	// even though the identical /lgtm comment from them succeeds.
+	if opts != nil && opts.RequireFileCoverage {
+		wantLGTM, ok := wantLGTMFromReviewState(re.Review.State)
+		if !ok {
+			return nil
+		}
+		ro, err := loadRepoOwners(gc, ownersClient, org, repo, number)
+		if err != nil {
+			return err
+		}
+		filenames, err := getChangedFiles(gc, org, repo, number)
+		if err != nil {
+			return err
+		}
+		return applyFileCoverage(gc, ro, log, org, repo, number, filenames, wantLGTM, login)
+	}
+
+	ok, err := canLGTM(gc, ownersClient, config, org, repo, number, login)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// Unlike an /lgtm comment, a review isn't an explicit command aimed
+		// at the bot, so an unqualified reviewer is silently ignored rather
+		// than answered with a rejection comment.
+		return nil
+	}
+
+	wantLGTM, ok := wantLGTMFromReviewState(re.Review.State)
+	if !ok {
+		return nil
+	}
+
+	if opts != nil && opts.ReviewersRequired > 1 {
+		return applyReviewerThreshold(gc, config, log, org, repo, number, opts.ReviewersRequired, wantLGTM, login)
+	}
+
+	return setLGTM(gc, config, org, repo, number, wantLGTM, log)
+}
+
+// wantLGTMFromReviewState maps a submitted GitHub review's state to whether
+// it requests the lgtm label be applied, if it requests anything at all.
+func wantLGTMFromReviewState(state github.ReviewState) (wantLGTM bool, ok bool) {
+	switch state {
+	case github.ReviewStateApproved:
+		return true, true
+	case github.ReviewStateChangesRequested, github.ReviewStateDismissed:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// reviewEventFromReviewState maps a submitted GitHub review's state to the
+// review-state event it requests, if any.
+func reviewEventFromReviewState(state github.ReviewState) (reviewEvent, bool) {
+	switch state {
+	case github.ReviewStateApproved:
+		return eventLGTM, true
+	case github.ReviewStateChangesRequested:
+		return eventHold, true
+	case github.ReviewStateDismissed:
+		return eventLGTMCancel, true
+	default:
+		return "", false
+	}
+}
+
 func handle(gc githubClient, config *plugins.Configuration, ownersClient repoowners.Interface, log *logrus.Entry, e *github.GenericCommentEvent) error {
 	// Only consider open PRs and new comments.
 	if !e.IsPR || e.IssueState != "open" || e.Action != github.GenericCommentActionCreated {
 		return nil
 	}
 
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+	commentAuthor := e.User.Login
+
+	opts := config.LgtmFor(org, repo)
+
+	// The review-state lifecycle folds the binary lgtm label into a
+	// three-state machine that also reacts to /hold, so it parses comments
+	// on its own rather than falling through the wantLGTM switch below.
+	if opts != nil && opts.ReviewStateLabels {
+		event, ok := reviewEventFromComment(e.Body)
+		if !ok {
+			return nil
+		}
+		return handleReviewState(gc, ownersClient, config, log, e, event, commentAuthor)
+	}
+
 	// If we create an "/lgtm" comment, add lgtm if necessary.
 	// If we create a "/lgtm cancel" comment, remove lgtm if necessary.
 	wantLGTM := false
@@ -95,9 +310,21 @@ func handle(gc githubClient, config *plugins.Configuration, ownersClient repoown
 		return nil
 	}
 
-	org := e.Repo.Owner.Login
-	repo := e.Repo.Name
-	commentAuthor := e.User.Login
+	// Per-file coverage mode replaces the assignee/collaborator gating below
+	// with OWNERS-scoped accounting: a reviewer's /lgtm only ever covers the
+	// files OWNERS says they can review, and the label isn't applied until
+	// every changed file is covered.
+	if opts != nil && opts.RequireFileCoverage {
+		return handleFileCoverage(gc, ownersClient, log, e, wantLGTM, commentAuthor)
+	}
+
+	// A required-reviewer threshold above the default of one also replaces
+	// the gating below with its own eligibility check, since the label
+	// decision depends on the full set of current LGTMers rather than just
+	// this commenter's intent.
+	if opts != nil && opts.ReviewersRequired > 1 {
+		return handleReviewersRequired(gc, ownersClient, config, log, e, wantLGTM, commentAuthor, opts.ReviewersRequired)
+	}
 
 	// Allow authors to cancel LGTM. Do not allow authors to LGTM, and do not
 	// accept commands from any other user.
@@ -134,26 +361,61 @@ func handle(gc githubClient, config *plugins.Configuration, ownersClient repoown
 		}
 	} else if !isAuthor && skipCollaborators {
 		log.Debugf("Skipping collaborator checks and loading OWNERS for %s/%s#%d", org, repo, e.Number)
-		ro, err := loadRepoOwners(gc, ownersClient, org, repo, e.Number)
-		if err != nil {
-			return err
-		}
-		filenames, err := getChangedFiles(gc, org, repo, e.Number)
+		ok, err := canLGTM(gc, ownersClient, config, org, repo, e.Number, commentAuthor)
 		if err != nil {
 			return err
 		}
-		if !loadReviewers(ro, filenames).Has(github.NormLogin(commentAuthor)) {
+		if !ok {
 			resp := "adding LGTM is restricted to approvers and reviewers in OWNERS files."
 			log.Infof("Reply to /lgtm request with comment: \"%s\"", resp)
 			return gc.CreateComment(org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, commentAuthor, resp))
 		}
 	}
 
-	// Only add the label if it doesn't have it, and vice versa.
+	return setLGTM(gc, config, org, repo, e.Number, wantLGTM, log)
+}
+
+// canLGTM reports whether login is currently allowed to apply or cancel the
+// lgtm label on the given PR: when skipCollaborators is set for the repo, a
+// reviewer/approver in OWNERS for some file the PR touches (assignee and
+// collaborator status are not checked); otherwise an assignee or a repo
+// collaborator. It's shared by the /lgtm comment path and the
+// native GitHub review path; unlike the comment path it never auto-assigns
+// the PR to login.
+func canLGTM(gc githubClient, ownersClient repoowners.Interface, config *plugins.Configuration, org, repo string, number int, login string) (bool, error) {
+	if skipCollaborators(config, org, repo) {
+		ro, err := loadRepoOwners(gc, ownersClient, org, repo, number)
+		if err != nil {
+			return false, err
+		}
+		filenames, err := getChangedFiles(gc, org, repo, number)
+		if err != nil {
+			return false, err
+		}
+		return loadReviewers(ro, filenames).Has(github.NormLogin(login)), nil
+	}
+
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return false, err
+	}
+	for _, assignee := range pr.Assignees {
+		if assignee.Login == login {
+			return true, nil
+		}
+	}
+	return gc.IsCollaborator(org, repo, login)
+}
+
+// setLGTM applies or removes the binary lgtm label to reflect wantLGTM,
+// along with the notification-comment cleanup and sticky-LGTM tree marker
+// that go with it. It's shared by the /lgtm comment path and the native
+// GitHub review path.
+func setLGTM(gc githubClient, config *plugins.Configuration, org, repo string, number int, wantLGTM bool, log *logrus.Entry) error {
 	hasLGTM := false
-	labels, err := gc.GetIssueLabels(org, repo, e.Number)
+	labels, err := gc.GetIssueLabels(org, repo, number)
 	if err != nil {
-		log.WithError(err).Errorf("Failed to get the labels on %s/%s#%d.", org, repo, e.Number)
+		log.WithError(err).Errorf("Failed to get the labels on %s/%s#%d.", org, repo, number)
 	}
 	for _, candidate := range labels {
 		if candidate.Name == lgtmLabel {
@@ -163,10 +425,10 @@ func handle(gc githubClient, config *plugins.Configuration, ownersClient repoown
 	}
 	if hasLGTM && !wantLGTM {
 		log.Info("Removing LGTM label.")
-		return gc.RemoveLabel(org, repo, e.Number, lgtmLabel)
+		return gc.RemoveLabel(org, repo, number, lgtmLabel)
 	} else if !hasLGTM && wantLGTM {
 		log.Info("Adding LGTM label.")
-		if err := gc.AddLabel(org, repo, e.Number, lgtmLabel); err != nil {
+		if err := gc.AddLabel(org, repo, number, lgtmLabel); err != nil {
 			return err
 		}
 		// Delete the LGTM removed noti after the LGTM label is added.
@@ -174,40 +436,637 @@ func handle(gc githubClient, config *plugins.Configuration, ownersClient repoown
 		if err != nil {
 			log.WithError(err).Errorf("Failed to get bot name.")
 		}
-		comments, err := gc.ListIssueComments(org, repo, e.Number)
+		comments, err := gc.ListIssueComments(org, repo, number)
 		if err != nil {
-			log.WithError(err).Errorf("Failed to get the list of issue comments on %s/%s#%d.", org, repo, e.Number)
+			log.WithError(err).Errorf("Failed to get the list of issue comments on %s/%s#%d.", org, repo, number)
 		}
 		for _, comment := range comments {
 			if comment.User.Login == botname && comment.Body == removeLGTMLabelNoti {
 				if err := gc.DeleteComment(org, repo, comment.ID); err != nil {
-					log.WithError(err).Errorf("Failed to delete comment from %s/%s#%d, ID:%d.", org, repo, e.Number, comment.ID)
+					log.WithError(err).Errorf("Failed to delete comment from %s/%s#%d, ID:%d.", org, repo, number, comment.ID)
 				}
 			}
 		}
+		if opts := config.LgtmFor(org, repo); opts != nil && opts.StickyLGTM {
+			if err := recordLGTMTree(gc, org, repo, number); err != nil {
+				log.WithError(err).Errorf("Failed to record the lgtm-tree marker on %s/%s#%d.", org, repo, number)
+			}
+		}
 	}
 	return nil
 }
 
+// handleReviewersRequired implements Lgtm.ReviewersRequired: the lgtm label
+// isn't applied until at least `required` distinct qualified reviewers'
+// most recent command was /lgtm (an /lgtm cancel drops that reviewer from
+// the set). The current set of LGTMers is persisted in a bot comment so it
+// survives restarts and reruns of this handler.
+func handleReviewersRequired(gc githubClient, ownersClient repoowners.Interface, config *plugins.Configuration, log *logrus.Entry, e *github.GenericCommentEvent, wantLGTM bool, commentAuthor string, required int) error {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+
+	if wantLGTM && commentAuthor == e.IssueAuthor.Login {
+		resp := "you cannot LGTM your own PR."
+		log.Infof("Commenting with \"%s\".", resp)
+		return gc.CreateComment(org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, commentAuthor, resp))
+	}
+
+	ok, err := canLGTM(gc, ownersClient, config, org, repo, e.Number, commentAuthor)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		resp := "adding LGTM is restricted to assignees, collaborators, and OWNERS reviewers/approvers."
+		log.Infof("Reply to /lgtm request with comment: \"%s\"", resp)
+		return gc.CreateComment(org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, commentAuthor, resp))
+	}
+
+	return applyReviewerThreshold(gc, config, log, org, repo, e.Number, required, wantLGTM, commentAuthor)
+}
+
+// applyReviewerThreshold implements the accounting behind
+// Lgtm.ReviewersRequired: it records or drops login from the persisted set
+// of LGTMers, then applies the lgtm label once required distinct logins
+// have LGTM'd. It's shared by the /lgtm comment path
+// (handleReviewersRequired) and the native GitHub review path (handleReview)
+// so a GitHub-native approval counts toward the threshold exactly like an
+// /lgtm comment does.
+func applyReviewerThreshold(gc githubClient, config *plugins.Configuration, log *logrus.Entry, org, repo string, number, required int, wantLGTM bool, login string) error {
+	lgtmers, comment, err := loadLGTMers(gc, org, repo, number)
+	if err != nil {
+		return err
+	}
+
+	login = github.NormLogin(login)
+	if wantLGTM {
+		lgtmers.Insert(login)
+	} else {
+		lgtmers.Delete(login)
+	}
+
+	if err := saveLGTMers(gc, org, repo, number, comment, lgtmers, required); err != nil {
+		log.WithError(err).Errorf("Failed to update the lgtm reviewers comment on %s/%s#%d.", org, repo, number)
+	}
+
+	return setLGTM(gc, config, org, repo, number, lgtmers.Len() >= required, log)
+}
+
+// lgtmReviewersClient is the subset of GitHub operations needed to read and
+// persist the lgtm-reviewers marker comment. It's satisfied by both
+// githubClient and ghLabelClient.
+type lgtmReviewersClient interface {
+	BotName() (string, error)
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	CreateComment(owner, repo string, number int, comment string) error
+	EditComment(org, repo string, ID int, comment string) error
+}
+
+// resetLGTMers clears the persisted lgtm-reviewers set on a push, so a
+// reviewer count gathered against the old commits can't silently satisfy
+// Lgtm.ReviewersRequired again without anyone having looked at the new ones.
+func resetLGTMers(gc lgtmReviewersClient, org, repo string, number, required int) error {
+	_, existing, err := loadLGTMers(gc, org, repo, number)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil
+	}
+	return saveLGTMers(gc, org, repo, number, existing, sets.String{}, required)
+}
+
+// loadLGTMers finds the bot's lgtm-reviewers comment, if any, and decodes
+// the set of logins whose most recent command was /lgtm.
+func loadLGTMers(gc lgtmReviewersClient, org, repo string, number int) (sets.String, *github.IssueComment, error) {
+	botname, err := gc.BotName()
+	if err != nil {
+		return nil, nil, err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := len(comments) - 1; i >= 0; i-- {
+		comment := comments[i]
+		if comment.User.Login != botname {
+			continue
+		}
+		m := lgtmReviewersCommentRe.FindStringSubmatch(comment.Body)
+		if m == nil {
+			continue
+		}
+		var logins []string
+		if err := json.Unmarshal([]byte(m[1]), &logins); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse lgtm-reviewers comment: %v", err)
+		}
+		return sets.NewString(logins...), &comment, nil
+	}
+	return sets.String{}, nil, nil
+}
+
+// saveLGTMers persists lgtmers by editing the existing status comment in
+// place, or creating one on the PR's first /lgtm, rendering who has LGTM'd
+// so far and how many more qualified reviewers are needed.
+func saveLGTMers(gc lgtmReviewersClient, org, repo string, number int, existing *github.IssueComment, lgtmers sets.String, required int) error {
+	encoded, err := json.Marshal(lgtmers.List())
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf(lgtmReviewersCommentFmt, encoded) + "\n" + renderLGTMersStatus(lgtmers, required)
+	if existing != nil {
+		return gc.EditComment(org, repo, existing.ID, body)
+	}
+	return gc.CreateComment(org, repo, number, body)
+}
+
+// renderLGTMersStatus renders the current LGTMers and the remaining count
+// needed to reach the repo's required threshold.
+func renderLGTMersStatus(lgtmers sets.String, required int) string {
+	var b strings.Builder
+	if lgtmers.Len() == 0 {
+		b.WriteString("No LGTMs yet. ")
+	} else {
+		fmt.Fprintf(&b, "Current LGTMs: %s. ", strings.Join(lgtmers.List(), ", "))
+	}
+	if remaining := required - lgtmers.Len(); remaining > 0 {
+		fmt.Fprintf(&b, "%d more qualified reviewer(s) needed to reach the required %d.", remaining, required)
+	} else {
+		b.WriteString("Reviewer threshold met.")
+	}
+	return b.String()
+}
+
+// reviewState is one of the three mutually exclusive labels in the
+// review-state lifecycle driven by Lgtm.ReviewStateLabels.
+type reviewState string
+
+const (
+	stateNeedsReview      reviewState = "needs-review"
+	stateChangesRequested reviewState = "changes-requested"
+	stateApproved         reviewState = "lgtm"
+)
+
+// reviewEvent is an input to the review-state transition table.
+type reviewEvent string
+
+const (
+	eventPush       reviewEvent = "push"
+	eventLGTM       reviewEvent = "lgtm"
+	eventLGTMCancel reviewEvent = "lgtm-cancel"
+	eventHold       reviewEvent = "hold"
+	eventHoldCancel reviewEvent = "hold-cancel"
+)
+
+// transition computes the review-state lifecycle's next state for old given
+// event. It's a pure function so the table below can be covered directly by
+// a unit test rather than through mocked GitHub calls.
+//
+//	old \ event      | push         | lgtm  | lgtm-cancel      | hold              | hold-cancel
+//	needs-review     | needs-review | lgtm  | needs-review     | changes-requested | needs-review
+//	changes-requested| needs-review | lgtm  | changes-requested| changes-requested | needs-review
+//	lgtm             | needs-review | lgtm  | needs-review     | changes-requested | lgtm
+func transition(old reviewState, event reviewEvent) reviewState {
+	switch event {
+	case eventPush:
+		return stateNeedsReview
+	case eventLGTM:
+		return stateApproved
+	case eventHold:
+		return stateChangesRequested
+	case eventLGTMCancel:
+		// Canceling an LGTM only has an effect if it's what put the PR into
+		// the approved state; it doesn't clear an unrelated hold.
+		if old == stateApproved {
+			return stateNeedsReview
+		}
+	case eventHoldCancel:
+		// Symmetrically, canceling a hold only clears changes-requested.
+		if old == stateChangesRequested {
+			return stateNeedsReview
+		}
+	}
+	return old
+}
+
+// labelForState returns the GitHub label that represents state.
+func labelForState(state reviewState) string {
+	return string(state)
+}
+
+// reviewStateClient is the subset of GitHub operations needed to read and
+// mutate the review-state lifecycle's labels. It's satisfied by both
+// githubClient and ghLabelClient.
+type reviewStateClient interface {
+	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
+	AddLabel(owner, repo string, number int, label string) error
+	RemoveLabel(owner, repo string, number int, label string) error
+}
+
+// currentReviewState inspects number's labels for the review-state lifecycle
+// labels and reports which one is currently applied. ok is false when none
+// of the three labels is present yet - e.g. a brand new PR, or one that
+// predates Lgtm.ReviewStateLabels being turned on - in which case old is
+// meaningless and callers must not skip applying a transition just because
+// it happens to equal old's zero value.
+func currentReviewState(gc reviewStateClient, org, repo string, number int, log *logrus.Entry) (old reviewState, ok bool, err error) {
+	labels, err := gc.GetIssueLabels(org, repo, number)
+	if err != nil {
+		return stateNeedsReview, false, err
+	}
+	for _, l := range labels {
+		switch l.Name {
+		case needsReviewLabel:
+			return stateNeedsReview, true, nil
+		case changesRequestedLabel:
+			return stateChangesRequested, true, nil
+		case lgtmLabel:
+			return stateApproved, true, nil
+		}
+	}
+	return stateNeedsReview, false, nil
+}
+
+// applyReviewState swaps the label for old to the label for next. hadLabel
+// must be the ok result of currentReviewState: when false, there's no live
+// label to compare against, so next is always applied; applyReviewState is
+// a no-op only when hadLabel is true and old == next.
+func applyReviewState(gc reviewStateClient, org, repo string, number int, log *logrus.Entry, old reviewState, hadLabel bool, next reviewState) error {
+	if hadLabel && old == next {
+		return nil
+	}
+	if hadLabel {
+		if err := gc.RemoveLabel(org, repo, number, labelForState(old)); err != nil {
+			if _, notFound := err.(*github.LabelNotFound); !notFound {
+				log.WithError(err).Errorf("Failed to remove the %s label on %s/%s#%d.", labelForState(old), org, repo, number)
+			}
+		}
+	}
+	log.Infof("Moving %s/%s#%d to %q.", org, repo, number, next)
+	return gc.AddLabel(org, repo, number, labelForState(next))
+}
+
+// handleReviewStatePush implements the opened/synchronize side of
+// Lgtm.ReviewStateLabels: a new PR or a push always resets the lifecycle to
+// needs-review, regardless of what label (if any) was there before.
+func handleReviewStatePush(gc reviewStateClient, org, repo string, number int, log *logrus.Entry) error {
+	old, ok, err := currentReviewState(gc, org, repo, number, log)
+	if err != nil {
+		return err
+	}
+	return applyReviewState(gc, org, repo, number, log, old, ok, transition(old, eventPush))
+}
+
+// reviewEventFromComment maps a comment body to the review-state event it
+// requests, if any.
+func reviewEventFromComment(body string) (reviewEvent, bool) {
+	switch {
+	case lgtmRe.MatchString(body):
+		return eventLGTM, true
+	case lgtmCancelRe.MatchString(body):
+		return eventLGTMCancel, true
+	case holdRe.MatchString(body):
+		return eventHold, true
+	case holdCancelRe.MatchString(body):
+		return eventHoldCancel, true
+	default:
+		return "", false
+	}
+}
+
+// handleReviewState implements Lgtm.ReviewStateLabels: the lgtm label is
+// folded into the needs-review/changes-requested/lgtm lifecycle driven by
+// /lgtm, /lgtm cancel, /hold, and /hold cancel commands via the transition
+// table above.
+func handleReviewState(gc githubClient, ownersClient repoowners.Interface, config *plugins.Configuration, log *logrus.Entry, e *github.GenericCommentEvent, event reviewEvent, commentAuthor string) error {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+
+	if event == eventLGTM && commentAuthor == e.IssueAuthor.Login {
+		resp := "you cannot LGTM your own PR."
+		log.Infof("Commenting with \"%s\".", resp)
+		return gc.CreateComment(org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, commentAuthor, resp))
+	}
+
+	ok, err := canLGTM(gc, ownersClient, config, org, repo, e.Number, commentAuthor)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		resp := "changing the review state is restricted to assignees, collaborators, and OWNERS reviewers/approvers."
+		log.Infof("Reply to review-state request with comment: \"%s\"", resp)
+		return gc.CreateComment(org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, commentAuthor, resp))
+	}
+
+	old, hadLabel, err := currentReviewState(gc, org, repo, e.Number, log)
+	if err != nil {
+		return err
+	}
+	return applyReviewState(gc, org, repo, e.Number, log, old, hadLabel, transition(old, event))
+}
+
+// handleFileCoverage implements Lgtm.RequireFileCoverage: a commenter's
+// /lgtm only counts for the files OWNERS says they can review or approve,
+// and the lgtm label is only applied once every file changed by the PR has
+// at least one LGTM from a qualified reviewer. Coverage is persisted in a
+// bot comment rendered as a file -> reviewer table.
+func handleFileCoverage(gc githubClient, ownersClient repoowners.Interface, log *logrus.Entry, e *github.GenericCommentEvent, wantLGTM bool, commentAuthor string) error {
+	org := e.Repo.Owner.Login
+	repo := e.Repo.Name
+
+	if wantLGTM && commentAuthor == e.IssueAuthor.Login {
+		resp := "you cannot LGTM your own PR."
+		log.Infof("Commenting with \"%s\".", resp)
+		return gc.CreateComment(org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, commentAuthor, resp))
+	}
+
+	ro, err := loadRepoOwners(gc, ownersClient, org, repo, e.Number)
+	if err != nil {
+		return err
+	}
+	filenames, err := getChangedFiles(gc, org, repo, e.Number)
+	if err != nil {
+		return err
+	}
+
+	if wantLGTM && len(filesOwnedBy(ro, filenames, github.NormLogin(commentAuthor))) == 0 {
+		resp := "adding LGTM is restricted to approvers and reviewers in OWNERS files, and you don't own any files changed by this PR."
+		log.Infof("Reply to /lgtm request with comment: \"%s\"", resp)
+		return gc.CreateComment(org, repo, e.Number, plugins.FormatResponseRaw(e.Body, e.HTMLURL, commentAuthor, resp))
+	}
+
+	return applyFileCoverage(gc, ro, log, org, repo, e.Number, filenames, wantLGTM, commentAuthor)
+}
+
+// applyFileCoverage implements the accounting behind Lgtm.RequireFileCoverage:
+// it records or clears login's LGTM on every file ro says it owns among
+// filenames, then recomputes the lgtm label against the updated coverage.
+// It's shared by the /lgtm comment path (handleFileCoverage) and the native
+// GitHub review path (handleReview) so a GitHub-native approval counts
+// toward per-file coverage exactly like an /lgtm comment does. A login that
+// owns none of filenames is a no-op, the same as commenting /lgtm cancel
+// would be.
+func applyFileCoverage(gc githubClient, ro repoowners.RepoOwnerInterface, log *logrus.Entry, org, repo string, number int, filenames []string, wantLGTM bool, login string) error {
+	state, comment, err := loadLGTMState(gc, org, repo, number)
+	if err != nil {
+		return err
+	}
+
+	login = github.NormLogin(login)
+	if wantLGTM {
+		state.addLGTM(login, filesOwnedBy(ro, filenames, login))
+	} else {
+		state.removeLGTM(login)
+	}
+
+	if err := saveLGTMState(gc, org, repo, number, comment, state, filenames); err != nil {
+		log.WithError(err).Errorf("Failed to update the lgtm coverage comment on %s/%s#%d.", org, repo, number)
+	}
+
+	return recomputeLabel(gc, org, repo, number, log, state.isFullyCovered(filenames))
+}
+
+// recomputeLabel toggles the lgtm label to match fullCoverage, making no
+// AddLabel/RemoveLabel call when the label is already in the right state.
+func recomputeLabel(gc githubClient, org, repo string, number int, log *logrus.Entry, fullCoverage bool) error {
+	labels, err := gc.GetIssueLabels(org, repo, number)
+	if err != nil {
+		log.WithError(err).Errorf("Failed to get the labels on %s/%s#%d.", org, repo, number)
+	}
+	hasLGTM := false
+	for _, candidate := range labels {
+		if candidate.Name == lgtmLabel {
+			hasLGTM = true
+			break
+		}
+	}
+	if hasLGTM && !fullCoverage {
+		log.Info("Removing LGTM label: file coverage is no longer complete.")
+		return gc.RemoveLabel(org, repo, number, lgtmLabel)
+	} else if !hasLGTM && fullCoverage {
+		log.Info("Adding LGTM label: every changed file now has a qualified LGTM.")
+		return gc.AddLabel(org, repo, number, lgtmLabel)
+	}
+	return nil
+}
+
+// filesOwnedBy returns the subset of filenames that login can review or
+// approve according to OWNERS. Unlike loadReviewers, which unions every
+// reviewer across the whole PR, this scopes ownership to a single login.
+func filesOwnedBy(ro repoowners.RepoOwnerInterface, filenames []string, login string) []string {
+	var owned []string
+	for _, filename := range filenames {
+		if ro.Approvers(filename).Has(login) || ro.Reviewers(filename).Has(login) {
+			owned = append(owned, filename)
+		}
+	}
+	return owned
+}
+
+// LGTMState tracks, file by file, which qualified reviewers have LGTM'd a PR
+// under Lgtm.RequireFileCoverage. It round-trips through a bot comment: the
+// JSON below is the source of truth, and the rendered table beneath it is
+// for humans.
+type LGTMState struct {
+	// Reviewers maps a changed file to the logins that have LGTM'd it.
+	Reviewers map[string][]string `json:"reviewers"`
+}
+
+func newLGTMState() *LGTMState {
+	return &LGTMState{Reviewers: map[string][]string{}}
+}
+
+// addLGTM records that login has LGTM'd filenames, replacing any LGTM login
+// previously held on other files.
+func (s *LGTMState) addLGTM(login string, filenames []string) {
+	s.removeLGTM(login)
+	for _, filename := range filenames {
+		s.Reviewers[filename] = append(s.Reviewers[filename], login)
+	}
+}
+
+// removeLGTM drops login's LGTM from every file it covered.
+func (s *LGTMState) removeLGTM(login string) {
+	for filename, logins := range s.Reviewers {
+		var kept []string
+		for _, l := range logins {
+			if l != login {
+				kept = append(kept, l)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.Reviewers, filename)
+		} else {
+			s.Reviewers[filename] = kept
+		}
+	}
+}
+
+// isFullyCovered reports whether every file in filenames has at least one
+// recorded LGTM.
+func (s *LGTMState) isFullyCovered(filenames []string) bool {
+	for _, filename := range filenames {
+		if len(s.Reviewers[filename]) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// lgtmStateClient is the subset of GitHub operations needed to read and
+// persist the lgtm-file-state marker comment. It's satisfied by both
+// githubClient and ghLabelClient.
+type lgtmStateClient interface {
+	BotName() (string, error)
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	CreateComment(owner, repo string, number int, comment string) error
+	EditComment(org, repo string, ID int, comment string) error
+}
+
+// resetLGTMState clears the persisted per-file coverage state on a push, so
+// a file's LGTM recorded against the old commits can't silently count as
+// coverage for content a later push introduced - mirroring resetLGTMers for
+// Lgtm.ReviewersRequired.
+func resetLGTMState(gc lgtmStateClient, org, repo string, number int) error {
+	_, existing, err := loadLGTMState(gc, org, repo, number)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return nil
+	}
+	return saveLGTMState(gc, org, repo, number, existing, newLGTMState(), nil)
+}
+
+// loadLGTMState finds the bot's per-file coverage comment, if any, and
+// decodes the LGTMState embedded in its hidden marker. It returns a fresh
+// empty state and a nil comment when one hasn't been posted yet.
+func loadLGTMState(gc lgtmStateClient, org, repo string, number int) (*LGTMState, *github.IssueComment, error) {
+	botname, err := gc.BotName()
+	if err != nil {
+		return nil, nil, err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := len(comments) - 1; i >= 0; i-- {
+		comment := comments[i]
+		if comment.User.Login != botname {
+			continue
+		}
+		m := lgtmStateCommentRe.FindStringSubmatch(comment.Body)
+		if m == nil {
+			continue
+		}
+		state := newLGTMState()
+		if err := json.Unmarshal([]byte(m[1]), state); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse lgtm-file-state comment: %v", err)
+		}
+		return state, &comment, nil
+	}
+	return newLGTMState(), nil, nil
+}
+
+// saveLGTMState persists state by editing the existing coverage comment in
+// place, or creating one on the PR's first /lgtm. The comment renders a
+// file -> reviewer table so authors can see what still needs review.
+func saveLGTMState(gc lgtmStateClient, org, repo string, number int, existing *github.IssueComment, state *LGTMState, filenames []string) error {
+	encoded, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf(lgtmStateCommentFmt, encoded) + "\n" + renderLGTMStateTable(state, filenames)
+	if existing != nil {
+		return gc.EditComment(org, repo, existing.ID, body)
+	}
+	return gc.CreateComment(org, repo, number, body)
+}
+
+// renderLGTMStateTable renders a markdown file -> reviewer(s) table so PR
+// participants can see at a glance what coverage remains.
+func renderLGTMStateTable(state *LGTMState, filenames []string) string {
+	var b strings.Builder
+	b.WriteString("Per-file LGTM coverage:\n\n")
+	b.WriteString("File | Reviewers\n")
+	b.WriteString("--- | ---\n")
+	for _, filename := range filenames {
+		reviewers := state.Reviewers[filename]
+		status := "**needs review**"
+		if len(reviewers) > 0 {
+			status = strings.Join(reviewers, ", ")
+		}
+		fmt.Fprintf(&b, "%s | %s\n", filename, status)
+	}
+	return b.String()
+}
+
 type ghLabelClient interface {
+	AddLabel(owner, repo string, number int, label string) error
 	RemoveLabel(owner, repo string, number int, label string) error
+	GetIssueLabels(org, repo string, number int) ([]github.Label, error)
 	CreateComment(owner, repo string, number int, comment string) error
+	EditComment(org, repo string, ID int, comment string) error
+	GetPullRequest(org, repo string, number int) (*github.PullRequest, error)
+	GetSingleCommit(org, repo, SHA string) (github.RepositoryCommit, error)
+	ListIssueComments(org, repo string, number int) ([]github.IssueComment, error)
+	DeleteComment(org, repo string, ID int) error
+	BotName() (string, error)
 }
 
-func handlePullRequest(gc ghLabelClient, pe github.PullRequestEvent, log *logrus.Entry) error {
+func handlePullRequest(gc ghLabelClient, pe github.PullRequestEvent, config *plugins.Configuration, log *logrus.Entry) error {
 	if pe.PullRequest.Merged {
 		return nil
 	}
 
+	org := pe.PullRequest.Base.Repo.Owner.Login
+	repo := pe.PullRequest.Base.Repo.Name
+	number := pe.PullRequest.Number
+
+	if opts := config.LgtmFor(org, repo); opts != nil && opts.ReviewStateLabels {
+		switch pe.Action {
+		case github.PullRequestActionOpened, github.PullRequestActionSynchronize:
+			return handleReviewStatePush(gc, org, repo, number, log)
+		default:
+			return nil
+		}
+	}
+
 	if pe.Action != github.PullRequestActionSynchronize {
 		return nil
 	}
 
 	// Don't bother checking if it has the label...it's a race, and we'll have
 	// to handle failure due to not being labeled anyway.
-	org := pe.PullRequest.Base.Repo.Owner.Login
-	repo := pe.PullRequest.Base.Repo.Name
-	number := pe.PullRequest.Number
+	if opts := config.LgtmFor(org, repo); opts != nil && opts.StickyLGTM {
+		unchanged, err := isTreeUnchanged(gc, org, repo, number, pe.PullRequest.Head.SHA)
+		if err != nil {
+			log.WithError(err).Errorf("Failed to check whether the tree changed on %s/%s#%d; falling back to removing the label.", org, repo, number)
+		} else if unchanged {
+			log.Infof("Keeping LGTM label on %s/%s#%d: push did not change the tree.", org, repo, number)
+			return nil
+		}
+	}
+
+	// A required-reviewer count is tracked independently of the lgtm label
+	// itself, so the push has to clear it too - otherwise a stale reviewer
+	// count gathered against the old commits could immediately re-satisfy
+	// the threshold on the next /lgtm.
+	if opts := config.LgtmFor(org, repo); opts != nil && opts.ReviewersRequired > 1 {
+		if err := resetLGTMers(gc, org, repo, number, opts.ReviewersRequired); err != nil {
+			log.WithError(err).Errorf("Failed to reset the lgtm reviewers comment on %s/%s#%d.", org, repo, number)
+		}
+	}
+
+	// Per-file coverage is tracked independently of the lgtm label too, so
+	// the push has to clear it as well - otherwise a file's stale entry from
+	// before this push would still count as covered in isFullyCovered, and
+	// the very next /lgtm from any qualified reviewer could silently
+	// re-apply the label without anyone having looked at the new push.
+	if opts := config.LgtmFor(org, repo); opts != nil && opts.RequireFileCoverage {
+		if err := resetLGTMState(gc, org, repo, number); err != nil {
+			log.WithError(err).Errorf("Failed to reset the lgtm coverage comment on %s/%s#%d.", org, repo, number)
+		}
+	}
 
 	var labelNotFound bool
 	if err := gc.RemoveLabel(org, repo, number, lgtmLabel); err != nil {
@@ -226,6 +1085,69 @@ func handlePullRequest(gc ghLabelClient, pe github.PullRequestEvent, log *logrus
 	return nil
 }
 
+// isTreeUnchanged reports whether the tree SHA recorded by the most recent
+// lgtm-tree marker comment matches the tree produced by headSHA. A match
+// means the push was a pure rebase or an empty merge commit and didn't
+// introduce any new content, so the lgtm label can stick around.
+func isTreeUnchanged(gc ghLabelClient, org, repo string, number int, headSHA string) (bool, error) {
+	_, wantTree, err := latestLGTMTree(gc, org, repo, number)
+	if err != nil || wantTree == "" {
+		return false, err
+	}
+	commit, err := gc.GetSingleCommit(org, repo, headSHA)
+	if err != nil {
+		return false, fmt.Errorf("failed getting commit %s: %v", headSHA, err)
+	}
+	return commit.Commit.Tree.SHA == wantTree, nil
+}
+
+// latestLGTMTree finds the bot's lgtm-tree marker comment, if any, and
+// returns it along with the tree SHA it recorded.
+func latestLGTMTree(gc ghLabelClient, org, repo string, number int) (*github.IssueComment, string, error) {
+	botname, err := gc.BotName()
+	if err != nil {
+		return nil, "", err
+	}
+	comments, err := gc.ListIssueComments(org, repo, number)
+	if err != nil {
+		return nil, "", err
+	}
+	for i := len(comments) - 1; i >= 0; i-- {
+		comment := comments[i]
+		if comment.User.Login != botname {
+			continue
+		}
+		if m := lgtmTreeCommentRe.FindStringSubmatch(comment.Body); m != nil {
+			return &comment, m[1], nil
+		}
+	}
+	return nil, "", nil
+}
+
+// recordLGTMTree records the tree SHA of the PR's current head by editing
+// the existing lgtm-tree marker comment in place, or creating one on the
+// PR's first /lgtm, so a later synchronize event that reproduces the same
+// tree can keep the lgtm label instead of losing it.
+func recordLGTMTree(gc ghLabelClient, org, repo string, number int) error {
+	pr, err := gc.GetPullRequest(org, repo, number)
+	if err != nil {
+		return err
+	}
+	commit, err := gc.GetSingleCommit(org, repo, pr.Head.SHA)
+	if err != nil {
+		return err
+	}
+	existing, _, err := latestLGTMTree(gc, org, repo, number)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf(lgtmTreeCommentFmt, commit.Commit.Tree.SHA)
+	if existing != nil {
+		return gc.EditComment(org, repo, existing.ID, body)
+	}
+	return gc.CreateComment(org, repo, number, body)
+}
+
 func skipCollaborators(config *plugins.Configuration, org, repo string) bool {
 	full := fmt.Sprintf("%s/%s", org, repo)
 	for _, elem := range config.Owners.SkipCollaborators {
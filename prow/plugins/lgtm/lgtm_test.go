@@ -0,0 +1,847 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package lgtm
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"k8s.io/test-infra/prow/github"
+	"k8s.io/test-infra/prow/plugins"
+	"k8s.io/test-infra/prow/repoowners"
+)
+
+func TestTransition(t *testing.T) {
+	cases := []struct {
+		name  string
+		old   reviewState
+		event reviewEvent
+		want  reviewState
+	}{
+		{"push from needs-review", stateNeedsReview, eventPush, stateNeedsReview},
+		{"push from changes-requested", stateChangesRequested, eventPush, stateNeedsReview},
+		{"push from lgtm", stateApproved, eventPush, stateNeedsReview},
+
+		{"lgtm from needs-review", stateNeedsReview, eventLGTM, stateApproved},
+		{"lgtm from changes-requested", stateChangesRequested, eventLGTM, stateApproved},
+		{"lgtm from lgtm", stateApproved, eventLGTM, stateApproved},
+
+		{"lgtm-cancel from needs-review", stateNeedsReview, eventLGTMCancel, stateNeedsReview},
+		{"lgtm-cancel from changes-requested is a no-op", stateChangesRequested, eventLGTMCancel, stateChangesRequested},
+		{"lgtm-cancel from lgtm", stateApproved, eventLGTMCancel, stateNeedsReview},
+
+		{"hold from needs-review", stateNeedsReview, eventHold, stateChangesRequested},
+		{"hold from changes-requested", stateChangesRequested, eventHold, stateChangesRequested},
+		{"hold from lgtm", stateApproved, eventHold, stateChangesRequested},
+
+		{"hold-cancel from needs-review", stateNeedsReview, eventHoldCancel, stateNeedsReview},
+		{"hold-cancel from changes-requested", stateChangesRequested, eventHoldCancel, stateNeedsReview},
+		{"hold-cancel from lgtm is a no-op", stateApproved, eventHoldCancel, stateApproved},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := transition(tc.old, tc.event); got != tc.want {
+				t.Errorf("transition(%s, %s) = %s, want %s", tc.old, tc.event, got, tc.want)
+			}
+		})
+	}
+}
+
+// fakeReviewStateClient is a minimal reviewStateClient backed by an in-memory
+// label set, used to exercise currentReviewState and applyReviewState
+// together the way handleReviewStatePush does.
+type fakeReviewStateClient struct {
+	labels map[string]bool
+	added  []string
+}
+
+func newFakeReviewStateClient(initial ...string) *fakeReviewStateClient {
+	f := &fakeReviewStateClient{labels: map[string]bool{}}
+	for _, l := range initial {
+		f.labels[l] = true
+	}
+	return f
+}
+
+func (f *fakeReviewStateClient) GetIssueLabels(org, repo string, number int) ([]github.Label, error) {
+	var out []github.Label
+	for name, present := range f.labels {
+		if present {
+			out = append(out, github.Label{Name: name})
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeReviewStateClient) AddLabel(owner, repo string, number int, label string) error {
+	f.labels[label] = true
+	f.added = append(f.added, label)
+	return nil
+}
+
+func (f *fakeReviewStateClient) RemoveLabel(owner, repo string, number int, label string) error {
+	delete(f.labels, label)
+	return nil
+}
+
+// TestHandleReviewStatePush guards against a regression where a PR with no
+// review-state label yet - a brand new PR, or one from before
+// Lgtm.ReviewStateLabels was enabled - never got needs-review applied: its
+// absent state was indistinguishable from an actual needs-review label, so
+// transition's needs-review-to-needs-review no-op short-circuited before
+// the label was ever added.
+func TestHandleReviewStatePush(t *testing.T) {
+	cases := []struct {
+		name      string
+		initial   []string
+		wantAdded bool
+	}{
+		{"new PR with no review-state label yet gets needs-review applied", nil, true},
+		{"push from lgtm resets to needs-review", []string{lgtmLabel}, true},
+		{"push from changes-requested resets to needs-review", []string{changesRequestedLabel}, true},
+		{"push while already needs-review is a true no-op", []string{needsReviewLabel}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			gc := newFakeReviewStateClient(tc.initial...)
+			log := logrus.NewEntry(logrus.New())
+
+			if err := handleReviewStatePush(gc, "org", "repo", 1, log); err != nil {
+				t.Fatalf("handleReviewStatePush: %v", err)
+			}
+			if !gc.labels[needsReviewLabel] {
+				t.Errorf("needs-review label not present after push, labels: %v", gc.labels)
+			}
+			if added := len(gc.added) > 0; added != tc.wantAdded {
+				t.Errorf("AddLabel called = %v, want %v (calls: %v)", added, tc.wantAdded, gc.added)
+			}
+		})
+	}
+}
+
+// fakeLGTMClient is a minimal githubClient backed by in-memory labels,
+// comments, a single PR, its changed files, and commit tree hashes. It
+// satisfies githubClient and therefore every narrower interface lgtm.go
+// derives from it (ghLabelClient, lgtmStateClient, lgtmReviewersClient,
+// reviewStateClient), so it's shared across the tests below.
+type fakeLGTMClient struct {
+	botname       string
+	labels        sets.String
+	comments      []github.IssueComment
+	nextCommentID int
+	pr            github.PullRequest
+	changes       []github.PullRequestChange
+	commits       map[string]github.RepositoryCommit
+	collaborators sets.String
+	assigned      []string
+}
+
+func newFakeLGTMClient() *fakeLGTMClient {
+	return &fakeLGTMClient{
+		botname:       "k8s-ci-robot",
+		labels:        sets.String{},
+		commits:       map[string]github.RepositoryCommit{},
+		collaborators: sets.String{},
+	}
+}
+
+func (f *fakeLGTMClient) IsCollaborator(owner, repo, login string) (bool, error) {
+	return f.collaborators.Has(login), nil
+}
+
+func (f *fakeLGTMClient) AddLabel(owner, repo string, number int, label string) error {
+	f.labels.Insert(label)
+	return nil
+}
+
+func (f *fakeLGTMClient) AssignIssue(owner, repo string, number int, assignees []string) error {
+	f.assigned = append(f.assigned, assignees...)
+	return nil
+}
+
+func (f *fakeLGTMClient) CreateComment(owner, repo string, number int, comment string) error {
+	f.nextCommentID++
+	f.comments = append(f.comments, github.IssueComment{
+		ID:   f.nextCommentID,
+		User: github.User{Login: f.botname},
+		Body: comment,
+	})
+	return nil
+}
+
+func (f *fakeLGTMClient) EditComment(org, repo string, ID int, comment string) error {
+	for i := range f.comments {
+		if f.comments[i].ID == ID {
+			f.comments[i].Body = comment
+			return nil
+		}
+	}
+	return fmt.Errorf("no comment with ID %d", ID)
+}
+
+func (f *fakeLGTMClient) RemoveLabel(owner, repo string, number int, label string) error {
+	if !f.labels.Has(label) {
+		return &github.LabelNotFound{}
+	}
+	f.labels.Delete(label)
+	return nil
+}
+
+func (f *fakeLGTMClient) GetIssueLabels(org, repo string, number int) ([]github.Label, error) {
+	var out []github.Label
+	for _, name := range f.labels.List() {
+		out = append(out, github.Label{Name: name})
+	}
+	return out, nil
+}
+
+func (f *fakeLGTMClient) GetPullRequest(org, repo string, number int) (*github.PullRequest, error) {
+	pr := f.pr
+	return &pr, nil
+}
+
+func (f *fakeLGTMClient) GetPullRequestChanges(org, repo string, number int) ([]github.PullRequestChange, error) {
+	return f.changes, nil
+}
+
+func (f *fakeLGTMClient) GetSingleCommit(org, repo, SHA string) (github.RepositoryCommit, error) {
+	commit, ok := f.commits[SHA]
+	if !ok {
+		return github.RepositoryCommit{}, fmt.Errorf("no commit with SHA %s", SHA)
+	}
+	return commit, nil
+}
+
+func (f *fakeLGTMClient) ListIssueComments(org, repo string, number int) ([]github.IssueComment, error) {
+	return f.comments, nil
+}
+
+func (f *fakeLGTMClient) DeleteComment(org, repo string, ID int) error {
+	for i, c := range f.comments {
+		if c.ID == ID {
+			f.comments = append(f.comments[:i], f.comments[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeLGTMClient) BotName() (string, error) {
+	return f.botname, nil
+}
+
+// TestStickyLGTMTreeMarker covers recordLGTMTree/latestLGTMTree/
+// isTreeUnchanged: the first /lgtm creates the lgtm-tree marker comment, a
+// later one edits it in place rather than appending a new one (0134f0f), and
+// a synchronize is only treated as tree-identical when the pushed commit's
+// tree SHA matches the one most recently recorded.
+func TestStickyLGTMTreeMarker(t *testing.T) {
+	gc := newFakeLGTMClient()
+	gc.pr = github.PullRequest{Head: github.PullRequestBranch{SHA: "sha1"}}
+	gc.commits["sha1"] = github.RepositoryCommit{Commit: github.Commit{Tree: github.Tree{SHA: "tree1"}}}
+
+	if err := recordLGTMTree(gc, "org", "repo", 1); err != nil {
+		t.Fatalf("recordLGTMTree: %v", err)
+	}
+	if len(gc.comments) != 1 {
+		t.Fatalf("want 1 comment after the first recordLGTMTree, got %d", len(gc.comments))
+	}
+	firstID := gc.comments[0].ID
+
+	_, tree, err := latestLGTMTree(gc, "org", "repo", 1)
+	if err != nil {
+		t.Fatalf("latestLGTMTree: %v", err)
+	}
+	if tree != "tree1" {
+		t.Errorf("latestLGTMTree tree = %q, want %q", tree, "tree1")
+	}
+
+	gc.pr.Head.SHA = "sha2"
+	gc.commits["sha2"] = github.RepositoryCommit{Commit: github.Commit{Tree: github.Tree{SHA: "tree2"}}}
+	if err := recordLGTMTree(gc, "org", "repo", 1); err != nil {
+		t.Fatalf("recordLGTMTree (second): %v", err)
+	}
+	if len(gc.comments) != 1 {
+		t.Fatalf("want the marker comment edited in place, got %d comments", len(gc.comments))
+	}
+	if gc.comments[0].ID != firstID {
+		t.Errorf("expected the same comment ID to be reused, got %d want %d", gc.comments[0].ID, firstID)
+	}
+
+	_, tree, err = latestLGTMTree(gc, "org", "repo", 1)
+	if err != nil {
+		t.Fatalf("latestLGTMTree (second): %v", err)
+	}
+	if tree != "tree2" {
+		t.Errorf("latestLGTMTree tree = %q, want %q", tree, "tree2")
+	}
+
+	cases := []struct {
+		name    string
+		headSHA string
+		want    bool
+	}{
+		{"a push landing on the recorded tree keeps the label", "sha2", true},
+		{"a push landing on a different tree drops the label", "sha1", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			unchanged, err := isTreeUnchanged(gc, "org", "repo", 1, tc.headSHA)
+			if err != nil {
+				t.Fatalf("isTreeUnchanged: %v", err)
+			}
+			if unchanged != tc.want {
+				t.Errorf("isTreeUnchanged(%s) = %v, want %v", tc.headSHA, unchanged, tc.want)
+			}
+		})
+	}
+}
+
+// fakeRepoOwners is a minimal repoowners.RepoOwnerInterface backed by
+// per-file approver/reviewer sets.
+type fakeRepoOwners struct {
+	approvers map[string]sets.String
+	reviewers map[string]sets.String
+}
+
+func (f *fakeRepoOwners) Approvers(path string) sets.String {
+	return f.approvers[path]
+}
+
+func (f *fakeRepoOwners) Reviewers(path string) sets.String {
+	return f.reviewers[path]
+}
+
+// fakeOwnersClient is a minimal repoowners.Interface that always hands back
+// the same fakeRepoOwners, regardless of org/repo/base.
+type fakeOwnersClient struct {
+	owners *fakeRepoOwners
+}
+
+func (f *fakeOwnersClient) LoadRepoOwners(org, repo, base string) (repoowners.RepoOwnerInterface, error) {
+	return f.owners, nil
+}
+
+func TestLGTMStateCoverage(t *testing.T) {
+	s := newLGTMState()
+	s.addLGTM("alice", []string{"a.go", "b.go"})
+	s.addLGTM("bob", []string{"c.go"})
+
+	if !s.isFullyCovered([]string{"a.go", "b.go", "c.go"}) {
+		t.Errorf("expected full coverage once every file has an LGTM")
+	}
+	if s.isFullyCovered([]string{"a.go", "d.go"}) {
+		t.Errorf("expected d.go, which has no LGTM, to report incomplete coverage")
+	}
+
+	// Re-LGTMing with a different file set replaces, rather than adds to,
+	// alice's prior coverage.
+	s.addLGTM("alice", []string{"c.go"})
+	if len(s.Reviewers["a.go"]) != 0 {
+		t.Errorf("expected alice's LGTM on a.go to be superseded, got %v", s.Reviewers["a.go"])
+	}
+	if got := s.Reviewers["c.go"]; len(got) != 2 {
+		t.Errorf("expected both alice and bob recorded on c.go, got %v", got)
+	}
+
+	s.removeLGTM("bob")
+	if len(s.Reviewers["c.go"]) != 1 {
+		t.Errorf("expected bob's LGTM removed from c.go, got %v", s.Reviewers["c.go"])
+	}
+}
+
+func TestFilesOwnedBy(t *testing.T) {
+	ro := &fakeRepoOwners{
+		approvers: map[string]sets.String{"a.go": sets.NewString("alice")},
+		reviewers: map[string]sets.String{"b.go": sets.NewString("alice"), "c.go": sets.NewString("bob")},
+	}
+	got := filesOwnedBy(ro, []string{"a.go", "b.go", "c.go"}, "alice")
+	want := []string{"a.go", "b.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("filesOwnedBy = %v, want %v", got, want)
+	}
+}
+
+// TestLGTMStateCommentRoundTrip covers loadLGTMState/saveLGTMState: the
+// first save creates the lgtm-file-state comment, and a later save edits it
+// in place and round-trips the JSON-encoded LGTMState.
+func TestLGTMStateCommentRoundTrip(t *testing.T) {
+	gc := newFakeLGTMClient()
+
+	state, existing, err := loadLGTMState(gc, "org", "repo", 1)
+	if err != nil {
+		t.Fatalf("loadLGTMState: %v", err)
+	}
+	if existing != nil {
+		t.Fatalf("expected no existing coverage comment before the first save")
+	}
+	state.addLGTM("alice", []string{"a.go"})
+	if err := saveLGTMState(gc, "org", "repo", 1, existing, state, []string{"a.go", "b.go"}); err != nil {
+		t.Fatalf("saveLGTMState: %v", err)
+	}
+	if len(gc.comments) != 1 {
+		t.Fatalf("want 1 comment after the first save, got %d", len(gc.comments))
+	}
+
+	reloaded, existing, err := loadLGTMState(gc, "org", "repo", 1)
+	if err != nil {
+		t.Fatalf("loadLGTMState (reload): %v", err)
+	}
+	if existing == nil {
+		t.Fatalf("expected the saved comment to round-trip back")
+	}
+	if got := reloaded.Reviewers["a.go"]; len(got) != 1 || got[0] != "alice" {
+		t.Errorf("reloaded state Reviewers[a.go] = %v, want [alice]", got)
+	}
+
+	reloaded.addLGTM("bob", []string{"b.go"})
+	if err := saveLGTMState(gc, "org", "repo", 1, existing, reloaded, []string{"a.go", "b.go"}); err != nil {
+		t.Fatalf("saveLGTMState (second): %v", err)
+	}
+	if len(gc.comments) != 1 {
+		t.Fatalf("want the coverage comment edited in place, got %d comments", len(gc.comments))
+	}
+}
+
+// TestResetLGTMStateOnPush guards against a regression where a push that
+// changed the content of an already-covered file left its stale
+// file->reviewer entry in place, letting the very next /lgtm from any
+// qualified reviewer - even one touching an unrelated file - silently
+// re-satisfy isFullyCovered for a push nobody had reviewed.
+func TestResetLGTMStateOnPush(t *testing.T) {
+	gc := newFakeLGTMClient()
+
+	state, existing, err := loadLGTMState(gc, "org", "repo", 1)
+	if err != nil {
+		t.Fatalf("loadLGTMState: %v", err)
+	}
+	state.addLGTM("alice", []string{"a.go"})
+	if err := saveLGTMState(gc, "org", "repo", 1, existing, state, []string{"a.go"}); err != nil {
+		t.Fatalf("saveLGTMState: %v", err)
+	}
+
+	if err := resetLGTMState(gc, "org", "repo", 1); err != nil {
+		t.Fatalf("resetLGTMState: %v", err)
+	}
+
+	reloaded, _, err := loadLGTMState(gc, "org", "repo", 1)
+	if err != nil {
+		t.Fatalf("loadLGTMState (after reset): %v", err)
+	}
+	if reloaded.isFullyCovered([]string{"a.go"}) {
+		t.Errorf("expected a.go's stale LGTM to be cleared by resetLGTMState, but it still reports covered")
+	}
+}
+
+// TestHandleFileCoverage drives handleFileCoverage end to end: the lgtm
+// label only appears once every changed file has been LGTM'd by a reviewer
+// OWNERS covers it for, and disappears again the moment a covering LGTM is
+// rescinded.
+func TestHandleFileCoverage(t *testing.T) {
+	gc := newFakeLGTMClient()
+	gc.collaborators = sets.NewString("alice", "bob")
+	gc.pr = github.PullRequest{Base: github.PullRequestBranch{Ref: "master"}}
+	gc.changes = []github.PullRequestChange{{Filename: "a.go"}, {Filename: "b.go"}}
+	ownersClient := &fakeOwnersClient{owners: &fakeRepoOwners{
+		approvers: map[string]sets.String{
+			"a.go": sets.NewString("alice"),
+			"b.go": sets.NewString("bob"),
+		},
+	}}
+	log := logrus.NewEntry(logrus.New())
+
+	event := func(login string) *github.GenericCommentEvent {
+		return &github.GenericCommentEvent{
+			Repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			Number:      1,
+			User:        github.User{Login: login},
+			IssueAuthor: github.User{Login: "pr-author"},
+			Body:        "/lgtm",
+			HTMLURL:     "https://github.com/org/repo/pull/1",
+		}
+	}
+
+	if err := handleFileCoverage(gc, ownersClient, log, event("alice"), true, "alice"); err != nil {
+		t.Fatalf("handleFileCoverage (alice): %v", err)
+	}
+	if gc.labels.Has(lgtmLabel) {
+		t.Errorf("lgtm label applied with b.go still uncovered")
+	}
+
+	if err := handleFileCoverage(gc, ownersClient, log, event("bob"), true, "bob"); err != nil {
+		t.Fatalf("handleFileCoverage (bob): %v", err)
+	}
+	if !gc.labels.Has(lgtmLabel) {
+		t.Errorf("expected lgtm label once every changed file is covered")
+	}
+
+	if err := handleFileCoverage(gc, ownersClient, log, event("alice"), false, "alice"); err != nil {
+		t.Fatalf("handleFileCoverage (alice cancel): %v", err)
+	}
+	if gc.labels.Has(lgtmLabel) {
+		t.Errorf("expected lgtm label removed once a.go lost its only LGTM")
+	}
+}
+
+// TestHandleReviewersRequired drives handleReviewersRequired end to end: the
+// lgtm label is gated on reaching Lgtm.ReviewersRequired distinct qualified
+// logins, an /lgtm cancel drops a login from the set, and resetLGTMers (the
+// fix commit) clears the persisted set on a push.
+func TestHandleReviewersRequired(t *testing.T) {
+	const required = 2
+	gc := newFakeLGTMClient()
+	gc.collaborators = sets.NewString("alice", "bob", "carol")
+	config := &plugins.Configuration{}
+	log := logrus.NewEntry(logrus.New())
+
+	event := func(login string) *github.GenericCommentEvent {
+		return &github.GenericCommentEvent{
+			Repo:        github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			Number:      1,
+			User:        github.User{Login: login},
+			IssueAuthor: github.User{Login: "pr-author"},
+			Body:        "/lgtm",
+			HTMLURL:     "https://github.com/org/repo/pull/1",
+		}
+	}
+
+	if err := handleReviewersRequired(gc, nil, config, log, event("alice"), true, "alice", required); err != nil {
+		t.Fatalf("handleReviewersRequired (alice): %v", err)
+	}
+	if gc.labels.Has(lgtmLabel) {
+		t.Errorf("lgtm label applied after only 1 of %d required reviewers", required)
+	}
+
+	if err := handleReviewersRequired(gc, nil, config, log, event("bob"), true, "bob", required); err != nil {
+		t.Fatalf("handleReviewersRequired (bob): %v", err)
+	}
+	if !gc.labels.Has(lgtmLabel) {
+		t.Errorf("expected lgtm label once %d reviewers LGTM'd", required)
+	}
+
+	if err := handleReviewersRequired(gc, nil, config, log, event("bob"), false, "bob", required); err != nil {
+		t.Fatalf("handleReviewersRequired (bob cancel): %v", err)
+	}
+	if gc.labels.Has(lgtmLabel) {
+		t.Errorf("expected lgtm label removed once back below the required threshold")
+	}
+
+	if err := handleReviewersRequired(gc, nil, config, log, event("bob"), true, "bob", required); err != nil {
+		t.Fatalf("handleReviewersRequired (bob re-lgtm): %v", err)
+	}
+	if !gc.labels.Has(lgtmLabel) {
+		t.Fatalf("setup: expected lgtm label restored before testing resetLGTMers")
+	}
+
+	if err := resetLGTMers(gc, "org", "repo", 1, required); err != nil {
+		t.Fatalf("resetLGTMers: %v", err)
+	}
+	lgtmers, _, err := loadLGTMers(gc, "org", "repo", 1)
+	if err != nil {
+		t.Fatalf("loadLGTMers: %v", err)
+	}
+	if lgtmers.Len() != 0 {
+		t.Errorf("expected resetLGTMers to clear the recorded set on push, got %v", lgtmers.List())
+	}
+}
+
+// TestCanLGTM covers the three ways canLGTM can qualify a login: repo
+// collaborator, PR assignee, and - for repos with skipCollaborators set -
+// an OWNERS reviewer/approver of a changed file.
+func TestCanLGTM(t *testing.T) {
+	t.Run("collaborator", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.collaborators = sets.NewString("alice")
+		config := &plugins.Configuration{}
+
+		ok, err := canLGTM(gc, nil, config, "org", "repo", 1, "alice")
+		if err != nil {
+			t.Fatalf("canLGTM: %v", err)
+		}
+		if !ok {
+			t.Errorf("expected a collaborator to be able to LGTM")
+		}
+
+		ok, err = canLGTM(gc, nil, config, "org", "repo", 1, "mallory")
+		if err != nil {
+			t.Fatalf("canLGTM: %v", err)
+		}
+		if ok {
+			t.Errorf("expected a non-collaborator, non-assignee login to be rejected")
+		}
+	})
+
+	t.Run("assignee", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.pr = github.PullRequest{Assignees: []github.User{{Login: "alice"}}}
+		config := &plugins.Configuration{}
+
+		ok, err := canLGTM(gc, nil, config, "org", "repo", 1, "alice")
+		if err != nil {
+			t.Fatalf("canLGTM: %v", err)
+		}
+		if !ok {
+			t.Errorf("expected an assignee to be able to LGTM even if not a collaborator")
+		}
+	})
+
+	t.Run("skipCollaborators uses OWNERS reviewers/approvers instead of collaborator status", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.changes = []github.PullRequestChange{{Filename: "a.go"}}
+		ownersClient := &fakeOwnersClient{owners: &fakeRepoOwners{
+			reviewers: map[string]sets.String{"a.go": sets.NewString("alice")},
+		}}
+		config := &plugins.Configuration{Owners: plugins.Owners{SkipCollaborators: []string{"org/repo"}}}
+
+		ok, err := canLGTM(gc, ownersClient, config, "org", "repo", 1, "alice")
+		if err != nil {
+			t.Fatalf("canLGTM: %v", err)
+		}
+		if !ok {
+			t.Errorf("expected an OWNERS reviewer of a changed file to be able to LGTM")
+		}
+
+		ok, err = canLGTM(gc, ownersClient, config, "org", "repo", 1, "bob")
+		if err != nil {
+			t.Fatalf("canLGTM: %v", err)
+		}
+		if ok {
+			t.Errorf("expected a login with no OWNERS coverage of the changed files to be rejected")
+		}
+	})
+}
+
+// TestHandleReview covers handleReview's use of a submitted GitHub review as
+// an alternate LGTM source: APPROVED applies the lgtm label, CHANGES_REQUESTED
+// and DISMISSED remove it, a self-review is skipped even if it would
+// otherwise qualify, and an unqualified reviewer is silently ignored rather
+// than answered with a rejection comment.
+func TestHandleReview(t *testing.T) {
+	config := &plugins.Configuration{}
+	log := logrus.NewEntry(logrus.New())
+
+	review := func(author, reviewer string, state github.ReviewState) *github.ReviewEvent {
+		return &github.ReviewEvent{
+			Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			PullRequest: github.PullRequest{
+				State:  "open",
+				Number: 1,
+				User:   github.User{Login: author},
+			},
+			Review: github.Review{
+				User:  github.User{Login: reviewer},
+				State: state,
+			},
+		}
+	}
+
+	t.Run("approve applies the lgtm label", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.collaborators = sets.NewString("alice")
+		if err := handleReview(gc, config, nil, log, review("carol", "alice", github.ReviewStateApproved)); err != nil {
+			t.Fatalf("handleReview: %v", err)
+		}
+		if !gc.labels.Has(lgtmLabel) {
+			t.Errorf("expected an approving review from a collaborator to apply lgtm")
+		}
+	})
+
+	t.Run("changes requested removes the lgtm label", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.collaborators = sets.NewString("alice")
+		gc.labels.Insert(lgtmLabel)
+		if err := handleReview(gc, config, nil, log, review("carol", "alice", github.ReviewStateChangesRequested)); err != nil {
+			t.Fatalf("handleReview: %v", err)
+		}
+		if gc.labels.Has(lgtmLabel) {
+			t.Errorf("expected a changes-requested review to remove lgtm")
+		}
+	})
+
+	t.Run("dismissed removes the lgtm label", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.collaborators = sets.NewString("alice")
+		gc.labels.Insert(lgtmLabel)
+		if err := handleReview(gc, config, nil, log, review("carol", "alice", github.ReviewStateDismissed)); err != nil {
+			t.Fatalf("handleReview: %v", err)
+		}
+		if gc.labels.Has(lgtmLabel) {
+			t.Errorf("expected a dismissed review to remove lgtm")
+		}
+	})
+
+	t.Run("self-review is ignored even if it would otherwise qualify", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.collaborators = sets.NewString("carol")
+		if err := handleReview(gc, config, nil, log, review("carol", "carol", github.ReviewStateApproved)); err != nil {
+			t.Fatalf("handleReview: %v", err)
+		}
+		if gc.labels.Has(lgtmLabel) {
+			t.Errorf("expected a self-review to be ignored")
+		}
+	})
+
+	t.Run("unqualified reviewer is silently ignored", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		if err := handleReview(gc, config, nil, log, review("carol", "mallory", github.ReviewStateApproved)); err != nil {
+			t.Fatalf("handleReview: %v", err)
+		}
+		if gc.labels.Has(lgtmLabel) {
+			t.Errorf("expected an unqualified reviewer's approval to be ignored")
+		}
+		if len(gc.comments) != 0 {
+			t.Errorf("expected no rejection comment for a review, unlike the /lgtm comment path")
+		}
+	})
+}
+
+// TestHandleReviewPerModeAccounting guards against a regression where a
+// native GitHub review bypassed Lgtm.RequireFileCoverage and
+// Lgtm.ReviewersRequired entirely: handleReview used to call setLGTM
+// directly for any review once ReviewStateLabels didn't apply, applying the
+// full lgtm label off a single qualifying approval instead of feeding the
+// same per-file coverage or reviewer-threshold accounting an /lgtm comment
+// would.
+func TestHandleReviewPerModeAccounting(t *testing.T) {
+	log := logrus.NewEntry(logrus.New())
+
+	review := func(author, reviewer string, state github.ReviewState) *github.ReviewEvent {
+		return &github.ReviewEvent{
+			Repo: github.Repo{Owner: github.User{Login: "org"}, Name: "repo"},
+			PullRequest: github.PullRequest{
+				State:  "open",
+				Number: 1,
+				User:   github.User{Login: author},
+			},
+			Review: github.Review{
+				User:  github.User{Login: reviewer},
+				State: state,
+			},
+		}
+	}
+
+	t.Run("RequireFileCoverage: a non-collaborator OWNERS reviewer isn't rejected by the collaborator/assignee gate", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.pr = github.PullRequest{Base: github.PullRequestBranch{Ref: "master"}}
+		gc.changes = []github.PullRequestChange{{Filename: "a.go"}}
+		ownersClient := &fakeOwnersClient{owners: &fakeRepoOwners{
+			approvers: map[string]sets.String{"a.go": sets.NewString("alice")},
+		}}
+		config := &plugins.Configuration{Lgtm: []plugins.Lgtm{{Repos: []string{"org/repo"}, RequireFileCoverage: true}}}
+
+		// alice is neither a repo collaborator nor a PR assignee - canLGTM
+		// would reject her, but RequireFileCoverage only cares about OWNERS
+		// ownership of the changed files, the same way the /lgtm comment
+		// path (handleFileCoverage) never calls canLGTM either.
+		if err := handleReview(gc, config, ownersClient, log, review("carol", "alice", github.ReviewStateApproved)); err != nil {
+			t.Fatalf("handleReview (alice): %v", err)
+		}
+		if !gc.labels.Has(lgtmLabel) {
+			t.Errorf("expected lgtm label from a non-collaborator OWNERS reviewer covering every changed file")
+		}
+	})
+
+	t.Run("RequireFileCoverage: a single approving review doesn't bypass per-file coverage", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.collaborators = sets.NewString("alice", "bob")
+		gc.pr = github.PullRequest{Base: github.PullRequestBranch{Ref: "master"}}
+		gc.changes = []github.PullRequestChange{{Filename: "a.go"}, {Filename: "b.go"}}
+		ownersClient := &fakeOwnersClient{owners: &fakeRepoOwners{
+			approvers: map[string]sets.String{
+				"a.go": sets.NewString("alice"),
+				"b.go": sets.NewString("bob"),
+			},
+		}}
+		config := &plugins.Configuration{Lgtm: []plugins.Lgtm{{Repos: []string{"org/repo"}, RequireFileCoverage: true}}}
+
+		if err := handleReview(gc, config, ownersClient, log, review("carol", "alice", github.ReviewStateApproved)); err != nil {
+			t.Fatalf("handleReview (alice): %v", err)
+		}
+		if gc.labels.Has(lgtmLabel) {
+			t.Errorf("lgtm label applied after a single review with b.go still uncovered")
+		}
+		if len(gc.comments) != 1 {
+			t.Fatalf("expected the lgtm-file-state comment to be created by the review, got %d comments", len(gc.comments))
+		}
+
+		if err := handleReview(gc, config, ownersClient, log, review("carol", "bob", github.ReviewStateApproved)); err != nil {
+			t.Fatalf("handleReview (bob): %v", err)
+		}
+		if !gc.labels.Has(lgtmLabel) {
+			t.Errorf("expected lgtm label once both reviews together cover every changed file")
+		}
+	})
+
+	t.Run("ReviewersRequired: a single approving review doesn't bypass the threshold", func(t *testing.T) {
+		const required = 2
+		gc := newFakeLGTMClient()
+		gc.collaborators = sets.NewString("alice", "bob")
+		config := &plugins.Configuration{Lgtm: []plugins.Lgtm{{Repos: []string{"org/repo"}, ReviewersRequired: required}}}
+
+		if err := handleReview(gc, config, nil, log, review("carol", "alice", github.ReviewStateApproved)); err != nil {
+			t.Fatalf("handleReview (alice): %v", err)
+		}
+		if gc.labels.Has(lgtmLabel) {
+			t.Errorf("lgtm label applied after only 1 of %d required reviewers", required)
+		}
+		if len(gc.comments) != 1 {
+			t.Fatalf("expected the lgtm-reviewers comment to be created by the review, got %d comments", len(gc.comments))
+		}
+
+		if err := handleReview(gc, config, nil, log, review("carol", "bob", github.ReviewStateApproved)); err != nil {
+			t.Fatalf("handleReview (bob): %v", err)
+		}
+		if !gc.labels.Has(lgtmLabel) {
+			t.Errorf("expected lgtm label once %d reviewers approved", required)
+		}
+	})
+
+	// ReviewStateLabels must take precedence over RequireFileCoverage when a
+	// repo enables both, matching handle()'s own ReviewStateLabels ->
+	// RequireFileCoverage -> ReviewersRequired dispatch order - otherwise an
+	// /lgtm comment and an equivalent native review would drive two
+	// different state machines on the same PR.
+	t.Run("ReviewStateLabels takes precedence over RequireFileCoverage", func(t *testing.T) {
+		gc := newFakeLGTMClient()
+		gc.collaborators = sets.NewString("alice")
+		gc.pr = github.PullRequest{Base: github.PullRequestBranch{Ref: "master"}}
+		gc.changes = []github.PullRequestChange{{Filename: "a.go"}}
+		ownersClient := &fakeOwnersClient{owners: &fakeRepoOwners{
+			approvers: map[string]sets.String{"a.go": sets.NewString("alice")},
+		}}
+		config := &plugins.Configuration{Lgtm: []plugins.Lgtm{{
+			Repos:               []string{"org/repo"},
+			ReviewStateLabels:   true,
+			RequireFileCoverage: true,
+		}}}
+
+		if err := handleReview(gc, config, ownersClient, log, review("carol", "alice", github.ReviewStateApproved)); err != nil {
+			t.Fatalf("handleReview: %v", err)
+		}
+		if !gc.labels.Has(lgtmLabel) {
+			t.Errorf("expected the review-state lifecycle to apply lgtm for a fully-covering approval")
+		}
+		if len(gc.comments) != 0 {
+			t.Errorf("expected no lgtm-file-state comment - ReviewStateLabels should have handled the review instead of RequireFileCoverage, got %d comments", len(gc.comments))
+		}
+	})
+}